@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// killTree stops a running command, optionally taking its whole descendant
+// tree down with it (kill_tree), and releases any OS resources it holds
+// open (a Windows job object, mainly) once the command has exited.
+type killTree interface {
+	kill()
+	close()
+}
+
+// limitedBuffer caps how much output a command can accumulate; once max
+// bytes have been written, further writes are silently discarded rather
+// than erroring, so a chatty command can't grow the response without bound.
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max <= 0 {
+		return b.buf.Write(p)
+	}
+	remaining := b.max - int64(b.buf.Len())
+	if remaining <= 0 {
+		return len(p), nil
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte { return b.buf.Bytes() }
+
+// runWithLimits starts cmd with its output capped by req.MaxOutputBytes and,
+// when req.TimeoutMs is set, kills it (and its descendants, if req.KillTree)
+// once the deadline passes instead of waiting forever like CombinedOutput.
+func runWithLimits(cmd *exec.Cmd, req Request) (output []byte, err error, timedOut bool, killed bool) {
+	buf := &limitedBuffer{max: req.MaxOutputBytes}
+	cmd.Stdout = buf
+	cmd.Stderr = buf
+
+	prepareKillTree(cmd, req.KillTree)
+
+	if startErr := cmd.Start(); startErr != nil {
+		return nil, startErr, false, false
+	}
+
+	kt := attachKillTree(cmd, req.KillTree)
+	defer kt.close()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	if req.TimeoutMs <= 0 {
+		waitErr := <-done
+		return buf.Bytes(), waitErr, false, false
+	}
+
+	select {
+	case waitErr := <-done:
+		return buf.Bytes(), waitErr, false, false
+	case <-time.After(time.Duration(req.TimeoutMs) * time.Millisecond):
+		// kt.kill() is a no-op on Windows when kill_tree wasn't requested (no
+		// job object was ever created), so cmd.Process.Kill() is what
+		// actually terminates the child in that case; kt.kill() still
+		// handles the kill_tree-enabled, whole-descendant-tree case.
+		cmd.Process.Kill()
+		kt.kill()
+		<-done
+		return buf.Bytes(), fmt.Errorf("command timed out after %dms", req.TimeoutMs), true, true
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// prepareKillTree puts the child in its own process group before it starts,
+// so kill_tree can later signal the whole group at once.
+func prepareKillTree(cmd *exec.Cmd, enabled bool) {
+	if !enabled {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+type pgKillTree struct {
+	pid     int
+	enabled bool
+}
+
+func attachKillTree(cmd *exec.Cmd, enabled bool) killTree {
+	return &pgKillTree{pid: cmd.Process.Pid, enabled: enabled}
+}
+
+func (k *pgKillTree) kill() {
+	if k.enabled {
+		syscall.Kill(-k.pid, syscall.SIGKILL)
+		return
+	}
+	syscall.Kill(k.pid, syscall.SIGKILL)
+}
+
+func (k *pgKillTree) close() {}
@@ -0,0 +1,89 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// prepareKillTree has nothing to do before Start on Windows; the job object
+// is created and the process assigned to it in attachKillTree instead,
+// since it needs the child's process handle.
+func prepareKillTree(cmd *exec.Cmd, enabled bool) {}
+
+type jobKillTree struct {
+	job     windows.Handle
+	enabled bool
+}
+
+// attachKillTree creates a job object with JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+// and assigns the just-started process to it, so closing the job (kill())
+// terminates the process and every descendant it spawned — important
+// because `cmd /C start` detaches the real workload from the handle we hold.
+func attachKillTree(cmd *exec.Cmd, enabled bool) killTree {
+	if !enabled {
+		return &jobKillTree{}
+	}
+
+	procHandle, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		return &jobKillTree{}
+	}
+	defer windows.CloseHandle(procHandle)
+
+	return attachKillTreeToHandle(procHandle, enabled)
+}
+
+// attachKillTreeToHandle is the handle-based half of attachKillTree, usable
+// by callers (like the ConPTY path) that already hold a process handle from
+// CreateProcess instead of an exec.Cmd.
+func attachKillTreeToHandle(procHandle windows.Handle, enabled bool) killTree {
+	if !enabled {
+		return &jobKillTree{}
+	}
+
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return &jobKillTree{}
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	windows.SetInformationJobObject(
+		job,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	)
+
+	if err := windows.AssignProcessToJobObject(job, procHandle); err != nil {
+		windows.CloseHandle(job)
+		return &jobKillTree{}
+	}
+
+	return &jobKillTree{job: job, enabled: true}
+}
+
+func (k *jobKillTree) kill() {
+	if !k.enabled {
+		return
+	}
+	// Closing the job with JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE set terminates
+	// every process still assigned to it. Mark disabled so the deferred
+	// close() doesn't close the same handle value again.
+	windows.CloseHandle(k.job)
+	k.enabled = false
+}
+
+func (k *jobKillTree) close() {
+	if k.enabled {
+		windows.CloseHandle(k.job)
+		k.enabled = false
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 	"unicode/utf16"
@@ -19,18 +20,32 @@ var (
 )
 
 type Request struct {
-	Command       string `json:"command"`
-	Type          string `json:"type,omitempty"`           // "cmd", "powershell", "wsl"
-	Headless      bool   `json:"headless,omitempty"`       // true for headless, false for headed
-	Verbose       bool   `json:"verbose,omitempty"`        // toggleable verbose logging
-	PersistWindow bool   `json:"persist_window,omitempty"` // true to keep window open, false to close when done
+	Command        string `json:"command"`
+	Type           string `json:"type,omitempty"`              // "cmd", "powershell", "wsl", "session_open", "session_close"
+	Headless       bool   `json:"headless,omitempty"`          // true for headless, false for headed
+	Verbose        bool   `json:"verbose,omitempty"`           // toggleable verbose logging
+	PersistWindow  bool   `json:"persist_window,omitempty"`    // true to keep window open, false to close when done
+	SessionID      string `json:"session_id,omitempty"`        // routes the command through a persistent shell process
+	SessionType    string `json:"session_type,omitempty"`      // shell for session_open: "cmd", "powershell", "wsl" (default "cmd")
+	Stream         bool   `json:"stream,omitempty"`            // true to emit incremental NDJSON frames instead of one blocking response
+	Pty            bool   `json:"pty,omitempty"`               // true to run the command attached to a pseudo-terminal
+	Rows           int    `json:"rows,omitempty"`              // pty rows, defaults to 24
+	Cols           int    `json:"cols,omitempty"`              // pty cols, defaults to 80
+	Input          string `json:"input,omitempty"`             // text written to the pty after the command starts (e.g. answering a prompt)
+	TimeoutMs      int64  `json:"timeout_ms,omitempty"`        // kill the command if it runs longer than this
+	MaxOutputBytes int64  `json:"max_output_bytes,omitempty"`  // truncate captured output beyond this size
+	KillTree       bool   `json:"kill_tree,omitempty"`        // kill the command's whole descendant tree, not just the immediate child
+	OutputFormat   string `json:"output_format,omitempty"`    // "json" (powershell, via ConvertTo-Json) or "lines" (cmd/wsl)
 }
 
 type Response struct {
-	Output   string `json:"output"`
-	Error    string `json:"error,omitempty"`
-	Duration int64  `json:"duration_ms"`
-	Debug    string `json:"debug,omitempty"` // verbose logging output
+	Output   string          `json:"output"`
+	Error    string          `json:"error,omitempty"`
+	Duration int64           `json:"duration_ms"`
+	Debug    string          `json:"debug,omitempty"` // verbose logging output
+	TimedOut bool            `json:"timed_out,omitempty"`
+	Killed   bool            `json:"killed,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"` // structured output_format result
 }
 
 func main() {
@@ -50,13 +65,35 @@ func main() {
 			req.Headless = true // default to headless for backwards compatibility
 		}
 
-		resp := executeCommand(req)
+		if req.Stream {
+			if req.Pty {
+				// streamCommand has no pty support; running it anyway would
+				// silently ignore req.Input and never allocate a pty.
+				writeJSON(streamDone{Done: true, Error: "stream and pty cannot be combined"})
+				continue
+			}
+			go streamCommand(req)
+			continue
+		}
 
-		output, _ := json.Marshal(resp)
-		fmt.Println(string(output))
+		resp := executeCommand(req)
+		writeJSON(resp)
 	}
 }
 
+// stdoutMu serializes writes to stdout so concurrent streaming frames and
+// regular responses never interleave into a broken JSON line.
+var stdoutMu sync.Mutex
+
+// writeJSON marshals v and prints it as a single line, holding stdoutMu for
+// the duration so it can't interleave with another goroutine's output.
+func writeJSON(v interface{}) {
+	output, _ := json.Marshal(v)
+	stdoutMu.Lock()
+	fmt.Println(string(output))
+	stdoutMu.Unlock()
+}
+
 func executeCommand(req Request) Response {
 	start := time.Now()
 
@@ -67,15 +104,47 @@ func executeCommand(req Request) Response {
 		debugInfo.WriteString(fmt.Sprintf("Type: %s, Headless: %t, PersistWindow: %t\n", req.Type, req.Headless, req.PersistWindow))
 	}
 
+	switch req.Type {
+	case "session_open":
+		return openSession(req, start, &debugInfo)
+	case "session_close":
+		return closeSession(req, start)
+	}
+
+	if req.SessionID != "" {
+		return executeInSession(req, start, &debugInfo)
+	}
+
+	if req.Pty {
+		return executePty(req, start, &debugInfo)
+	}
+
+	effectiveCommand := req.Command
+	isPowerShell := req.Type == "powershell" || req.Type == "ps"
+	if req.OutputFormat == "json" && isPowerShell {
+		effectiveCommand = wrapPowerShellJSON(req.Command)
+		if req.Verbose {
+			debugInfo.WriteString("Wrapping command with ConvertTo-Json for structured output\n")
+		}
+	}
+
 	var cmd *exec.Cmd
 
 	switch req.Type {
 	case "powershell", "ps":
-		cmd = exec.Command("powershell", "-Command", req.Command)
+		if req.OutputFormat == "json" {
+			// -NoProfile skips profile-loading side effects that could add
+			// unrelated host writes to the transcript; -OutputFormat Text
+			// keeps stdout as plain text so splitPowerShellJSON's line-based
+			// parsing sees exactly what ConvertTo-Json emitted.
+			cmd = exec.Command("powershell", "-NoProfile", "-OutputFormat", "Text", "-Command", effectiveCommand)
+		} else {
+			cmd = exec.Command("powershell", "-Command", effectiveCommand)
+		}
 	case "wsl":
-		cmd = exec.Command("wsl", "--", "bash", "-c", req.Command)
+		cmd = exec.Command("wsl", "--", "bash", "-c", effectiveCommand)
 	default: // "cmd" or empty
-		cmd = exec.Command("cmd", "/C", req.Command)
+		cmd = exec.Command("cmd", "/C", effectiveCommand)
 	}
 
 	// Configure window visibility based on headless flag
@@ -93,13 +162,13 @@ func executeCommand(req Request) Response {
 			switch req.Type {
 			case "powershell", "ps":
 				// Launch PowerShell in a new window
-				cmd = exec.Command("powershell", "-NoExit", "-Command", req.Command)
+				cmd = exec.Command("powershell", "-NoExit", "-Command", effectiveCommand)
 			case "wsl":
 				// Launch WSL in a new window using cmd start
-				cmd = exec.Command("cmd", "/C", "start", "wsl", "--", "bash", "-c", req.Command+"; read -p 'Press Enter to close...'")
+				cmd = exec.Command("cmd", "/C", "start", "wsl", "--", "bash", "-c", effectiveCommand+"; read -p 'Press Enter to close...'")
 			default: // "cmd" or empty
 				// Launch cmd in a new window
-				cmd = exec.Command("cmd", "/C", "start", "cmd", "/K", req.Command)
+				cmd = exec.Command("cmd", "/C", "start", "cmd", "/K", effectiveCommand)
 			}
 
 			cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -110,9 +179,9 @@ func executeCommand(req Request) Response {
 			// Non-Windows fallback (Linux/Mac)
 			switch req.Type {
 			case "powershell", "ps":
-				cmd = exec.Command("pwsh", "-Command", req.Command) // PowerShell Core
+				cmd = exec.Command("pwsh", "-Command", effectiveCommand) // PowerShell Core
 			default:
-				cmd = exec.Command("bash", "-c", req.Command)
+				cmd = exec.Command("bash", "-c", effectiveCommand)
 			}
 			cmd.SysProcAttr = &syscall.SysProcAttr{}
 		}
@@ -126,7 +195,7 @@ func executeCommand(req Request) Response {
 		debugInfo.WriteString(fmt.Sprintf("Command args: %v\n", cmd.Args))
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err, timedOut, killed := runWithLimits(cmd, req)
 	duration := time.Since(start).Milliseconds()
 
 	// Process output with UTF-16 detection and decoding
@@ -135,6 +204,17 @@ func executeCommand(req Request) Response {
 	resp := Response{
 		Output:   strings.TrimSpace(processedOutput),
 		Duration: duration,
+		TimedOut: timedOut,
+		Killed:   killed,
+	}
+
+	switch {
+	case req.OutputFormat == "json" && isPowerShell:
+		plain, data := splitPowerShellJSON(resp.Output)
+		resp.Output = plain
+		resp.Data = data
+	case req.OutputFormat == "lines" && !isPowerShell:
+		resp.Data = splitLines(resp.Output)
 	}
 
 	if err != nil {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonMarker tags each success-stream item once wrapPowerShellJSON has run,
+// so splitPowerShellJSON can tell machine-readable ConvertTo-Json output
+// apart from ordinary host writes (Write-Warning, Write-Verbose, etc.)
+// sharing the same *>&1 stream.
+const jsonMarker = "##GWPS-JSON##"
+
+// nonSuccessRecordTypes are the PowerShell record types every non-success
+// stream (error, warning, verbose, debug, information) turns into once
+// merged onto the pipeline by *>&1. Anything else came from the success
+// stream (stream 1) and is real pipeline output.
+const nonSuccessRecordTypes = `$_ -is [System.Management.Automation.ErrorRecord] -or ` +
+	`$_ -is [System.Management.Automation.WarningRecord] -or ` +
+	`$_ -is [System.Management.Automation.VerboseRecord] -or ` +
+	`$_ -is [System.Management.Automation.DebugRecord] -or ` +
+	`$_ -is [System.Management.Automation.InformationRecord]`
+
+// wrapPowerShellJSON rewrites command so its output streams are merged via
+// *>&1, then split back apart by type: non-success records (host writes)
+// pass through untagged as plain text, while success-stream objects are
+// each serialized with ConvertTo-Json and tagged with jsonMarker.
+// splitPowerShellJSON uses that tag to pull the real pipeline objects back
+// out of the transcript.
+func wrapPowerShellJSON(command string) string {
+	return fmt.Sprintf(
+		`& { %s } *>&1 | ForEach-Object { if (%s) { $_.ToString() } else { "%s" + ($_ | ConvertTo-Json -Depth 10 -Compress) } }`,
+		command, nonSuccessRecordTypes, jsonMarker,
+	)
+}
+
+// splitPowerShellJSON splits a wrapPowerShellJSON transcript back into plain
+// text output and a Data array of the decoded ConvertTo-Json fragments. A
+// command that emits nothing on the pipeline yields a nil Data, matching the
+// untagged path.
+func splitPowerShellJSON(output string) (plain string, data json.RawMessage) {
+	var textLines []string
+	var fragments []string
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, jsonMarker) {
+			fragments = append(fragments, strings.TrimPrefix(line, jsonMarker))
+			continue
+		}
+		textLines = append(textLines, line)
+	}
+
+	plain = strings.TrimSpace(strings.Join(textLines, "\n"))
+	if len(fragments) == 0 {
+		return plain, nil
+	}
+
+	return plain, json.RawMessage("[" + strings.Join(fragments, ",") + "]")
+}
+
+// splitLines turns plain multi-line output into a Data array of its
+// non-empty lines, for output_format: "lines" on cmd/wsl.
+func splitLines(output string) json.RawMessage {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	encoded, _ := json.Marshal(lines)
+	return encoded
+}
@@ -0,0 +1,38 @@
+package main
+
+import "strings"
+
+const (
+	defaultPtyRows = 24
+	defaultPtyCols = 80
+)
+
+// ptyCommandArgs builds the argv for running req.Command under a
+// pseudo-terminal, mirroring the shell dispatch used by executeCommand.
+func ptyCommandArgs(req Request) (string, []string) {
+	switch req.Type {
+	case "powershell", "ps":
+		return "powershell", []string{"-Command", req.Command}
+	case "wsl":
+		return "wsl", []string{"--", "bash", "-c", req.Command}
+	default: // "cmd" or empty
+		return "cmd", []string{"/C", req.Command}
+	}
+}
+
+func ptySize(req Request) (rows, cols int) {
+	rows, cols = req.Rows, req.Cols
+	if rows <= 0 {
+		rows = defaultPtyRows
+	}
+	if cols <= 0 {
+		cols = defaultPtyCols
+	}
+	return rows, cols
+}
+
+// trimPtyOutput tidies up the raw pty transcript the same way the plain
+// exec path trims CombinedOutput.
+func trimPtyOutput(s string) string {
+	return strings.TrimSpace(s)
+}
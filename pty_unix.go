@@ -0,0 +1,101 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// executePty runs req.Command with its stdio attached to a pseudo-terminal
+// via creack/pty, so interactive programs (ssh prompts, sudo, npm wizards)
+// behave the same as they would in a real terminal. Like the plain exec
+// path, req.TimeoutMs/MaxOutputBytes/KillTree bound it instead of letting a
+// stuck program hang forever.
+func executePty(req Request, start time.Time, debugInfo *strings.Builder) Response {
+	name, args := ptyCommandArgs(req)
+	cmd := exec.Command(name, args...)
+	prepareKillTree(cmd, req.KillTree)
+
+	rows, cols := ptySize(req)
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+	if err != nil {
+		return Response{Error: err.Error(), Duration: time.Since(start).Milliseconds()}
+	}
+	defer ptmx.Close()
+
+	kt := attachKillTree(cmd, req.KillTree)
+	defer kt.close()
+
+	if req.Verbose {
+		debugInfo.WriteString(fmt.Sprintf("Allocated pty %dx%d\n", rows, cols))
+	}
+
+	if req.Input != "" {
+		io.WriteString(ptmx, req.Input)
+	}
+
+	output, waitErr, readErr, timedOut, killed := readPtyWithLimits(ptmx, cmd, kt, req)
+	duration := time.Since(start).Milliseconds()
+
+	processedOutput := processOutput(output, req.Verbose, debugInfo)
+	resp := Response{
+		Output:   trimPtyOutput(processedOutput),
+		Duration: duration,
+		TimedOut: timedOut,
+		Killed:   killed,
+	}
+
+	// A closed pty master surfaces as a read error once the child exits;
+	// that's expected and not a real failure.
+	if waitErr != nil {
+		resp.Error = waitErr.Error()
+	} else if readErr != nil && readErr != io.EOF {
+		resp.Error = readErr.Error()
+	}
+
+	if req.Verbose {
+		debugInfo.WriteString(fmt.Sprintf("Execution completed in %dms\n", duration))
+		resp.Debug = debugInfo.String()
+	}
+
+	return resp
+}
+
+// readPtyWithLimits reads the pty transcript while the child runs, capping
+// it at req.MaxOutputBytes, and kills the child (kt.kill, which respects
+// req.KillTree) if it's still running after req.TimeoutMs.
+func readPtyWithLimits(r io.Reader, cmd *exec.Cmd, kt killTree, req Request) (output []byte, waitErr error, readErr error, timedOut bool, killed bool) {
+	buf := &limitedBuffer{max: req.MaxOutputBytes}
+
+	readDone := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(buf, r)
+		readDone <- err
+	}()
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- cmd.Wait() }()
+
+	if req.TimeoutMs <= 0 {
+		waitErr = <-waitDone
+		readErr = <-readDone
+		return buf.Bytes(), waitErr, readErr, false, false
+	}
+
+	select {
+	case waitErr = <-waitDone:
+		readErr = <-readDone
+		return buf.Bytes(), waitErr, readErr, false, false
+	case <-time.After(time.Duration(req.TimeoutMs) * time.Millisecond):
+		kt.kill()
+		<-waitDone
+		<-readDone
+		return buf.Bytes(), fmt.Errorf("command timed out after %dms", req.TimeoutMs), nil, true, true
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionIdleTimeout controls how long a session can sit unused before the
+// reaper closes it and frees the underlying shell process.
+const sessionIdleTimeout = 15 * time.Minute
+
+// sentinelPrefix marks the end of a command's output so we can demultiplex
+// the persistent shell's stdout stream back into discrete responses.
+const sentinelPrefix = "__END__"
+
+// session wraps a long-lived shell process (powershell/cmd/wsl) whose stdin
+// and stdout stay open across multiple requests, preserving working
+// directory, environment variables, imported modules, and auth tokens.
+type session struct {
+	id       string
+	shell    string
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   *bufio.Reader
+	mu       sync.Mutex
+	lastUsed time.Time
+	pending  chan sessionReadResult // set when the previous command timed out and is still draining
+}
+
+// sessionManager tracks all open sessions by id.
+type sessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+var sessions = &sessionManager{sessions: make(map[string]*session)}
+
+func init() {
+	go sessions.reapIdle()
+}
+
+// reapIdle periodically closes sessions that have been idle longer than
+// sessionIdleTimeout, so a forgotten session_id doesn't leak a shell process.
+func (m *sessionManager) reapIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.mu.Lock()
+		for id, s := range m.sessions {
+			s.mu.Lock()
+			idle := time.Since(s.lastUsed)
+			s.mu.Unlock()
+			if idle > sessionIdleTimeout {
+				s.close()
+				delete(m.sessions, id)
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (m *sessionManager) open(id, shellType string) (*session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[id]; ok {
+		return s, nil
+	}
+
+	s, err := newSession(id, shellType)
+	if err != nil {
+		return nil, err
+	}
+	m.sessions[id] = s
+	return s, nil
+}
+
+func (m *sessionManager) get(id string) (*session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+func (m *sessionManager) close(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return false
+	}
+	s.close()
+	delete(m.sessions, id)
+	return true
+}
+
+func newSession(id, shellType string) (*session, error) {
+	var cmd *exec.Cmd
+	switch shellType {
+	case "powershell", "ps":
+		cmd = exec.Command("powershell", "-NoLogo", "-NoExit", "-Command", "-")
+	case "wsl":
+		cmd = exec.Command("wsl", "--", "bash")
+	default: // "cmd" or empty
+		// /V:ON enables delayed expansion so withSentinel can read
+		// !errorlevel! after command has run instead of %errorlevel%, which
+		// cmd.exe would otherwise expand while parsing the whole line
+		// up front, before command even executes.
+		cmd = exec.Command("cmd", "/V:ON")
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &session{
+		id:       id,
+		shell:    shellType,
+		cmd:      cmd,
+		stdin:    stdin,
+		stdout:   bufio.NewReader(stdout),
+		lastUsed: time.Now(),
+	}, nil
+}
+
+// sessionReadResult is what the background reader in run() produces once it
+// reaches the sentinel (or the pipe errors out).
+type sessionReadResult struct {
+	output   string
+	exitCode int
+	err      error
+}
+
+// run sends command to the persistent shell, appends a sentinel marker that
+// echoes the exit code, and reads output up to that marker. Concurrent
+// requests to the same session serialize on s.mu. When timeoutMs is set and
+// the marker doesn't show up in time, run gives up and reports timedOut
+// instead of blocking forever, but the read continues in the background
+// (tracked via s.pending) so a later call can drain the stale output before
+// sending its own command.
+func (s *session) run(command string, timeoutMs int64, maxOutputBytes int64) (output string, exitCode int, err error, timedOut bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+
+	if s.pending != nil {
+		<-s.pending
+		s.pending = nil
+	}
+
+	marker := fmt.Sprintf("%s%d", sentinelPrefix, time.Now().UnixNano())
+	line := withSentinel(s.shell, command, marker)
+
+	if _, err = io.WriteString(s.stdin, line+"\n"); err != nil {
+		return "", -1, err, false
+	}
+
+	resultCh := make(chan sessionReadResult, 1)
+	go func() {
+		out, code, rerr := s.readUntilMarker(marker)
+		resultCh <- sessionReadResult{out, code, rerr}
+	}()
+
+	if timeoutMs <= 0 {
+		res := <-resultCh
+		return truncate(res.output, maxOutputBytes), res.exitCode, res.err, false
+	}
+
+	select {
+	case res := <-resultCh:
+		return truncate(res.output, maxOutputBytes), res.exitCode, res.err, false
+	case <-time.After(time.Duration(timeoutMs) * time.Millisecond):
+		s.pending = resultCh
+		return "", -1, fmt.Errorf("command timed out after %dms", timeoutMs), true
+	}
+}
+
+// readUntilMarker reads lines from the session's stdout until it finds
+// marker, returning everything before it plus the exit code the marker
+// carries.
+func (s *session) readUntilMarker(marker string) (output string, exitCode int, err error) {
+	var out strings.Builder
+	for {
+		rawLine, rerr := s.stdout.ReadString('\n')
+		if idx := strings.Index(rawLine, marker); idx >= 0 {
+			out.WriteString(rawLine[:idx])
+			code, _ := strconv.Atoi(strings.TrimSpace(rawLine[idx+len(marker):]))
+			return strings.TrimSpace(out.String()), code, nil
+		}
+		out.WriteString(rawLine)
+		if rerr != nil {
+			return strings.TrimSpace(out.String()), -1, rerr
+		}
+	}
+}
+
+// truncate caps output at maxBytes, matching the plain exec path's
+// max_output_bytes behavior. maxBytes <= 0 means unlimited.
+func truncate(output string, maxBytes int64) string {
+	if maxBytes <= 0 || int64(len(output)) <= maxBytes {
+		return output
+	}
+	return output[:maxBytes]
+}
+
+// withSentinel builds the command line appended with a marker that reports
+// the shell's exit code, so run() knows where this command's output ends.
+func withSentinel(shellType, command, marker string) string {
+	switch shellType {
+	case "powershell", "ps":
+		return fmt.Sprintf("%s; Write-Host \"%s$LASTEXITCODE\"", command, marker)
+	case "wsl":
+		return fmt.Sprintf("%s; echo \"%s$?\"", command, marker)
+	default: // "cmd" or empty
+		return fmt.Sprintf("%s & echo %s!errorlevel!", command, marker)
+	}
+}
+
+func (s *session) close() {
+	s.stdin.Close()
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+	s.cmd.Wait()
+}
+
+// openSession creates (or reuses) the persistent shell for req.SessionID.
+func openSession(req Request, start time.Time, debugInfo *strings.Builder) Response {
+	if req.SessionID == "" {
+		return Response{Error: "session_open requires session_id", Duration: time.Since(start).Milliseconds()}
+	}
+
+	if _, err := sessions.open(req.SessionID, req.SessionType); err != nil {
+		return Response{Error: err.Error(), Duration: time.Since(start).Milliseconds()}
+	}
+
+	if req.Verbose {
+		debugInfo.WriteString(fmt.Sprintf("Opened session %q\n", req.SessionID))
+	}
+
+	return Response{
+		Output:   fmt.Sprintf("session %s opened", req.SessionID),
+		Duration: time.Since(start).Milliseconds(),
+		Debug:    debugInfo.String(),
+	}
+}
+
+// closeSession tears down the persistent shell for req.SessionID.
+func closeSession(req Request, start time.Time) Response {
+	if req.SessionID == "" {
+		return Response{Error: "session_close requires session_id", Duration: time.Since(start).Milliseconds()}
+	}
+
+	if !sessions.close(req.SessionID) {
+		return Response{Error: fmt.Sprintf("no session %q open", req.SessionID), Duration: time.Since(start).Milliseconds()}
+	}
+
+	return Response{
+		Output:   fmt.Sprintf("session %s closed", req.SessionID),
+		Duration: time.Since(start).Milliseconds(),
+	}
+}
+
+// executeInSession runs req.Command against the existing session, opening
+// one on the fly (keyed by req.Type) if it doesn't exist yet.
+func executeInSession(req Request, start time.Time, debugInfo *strings.Builder) Response {
+	s, err := sessions.open(req.SessionID, req.Type)
+	if err != nil {
+		return Response{Error: err.Error(), Duration: time.Since(start).Milliseconds()}
+	}
+
+	if req.Verbose {
+		debugInfo.WriteString(fmt.Sprintf("Running in session %q\n", req.SessionID))
+	}
+
+	output, exitCode, err, timedOut := s.run(req.Command, req.TimeoutMs, req.MaxOutputBytes)
+	resp := Response{
+		Output:   output,
+		Duration: time.Since(start).Milliseconds(),
+		TimedOut: timedOut,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	} else if exitCode != 0 {
+		resp.Error = fmt.Sprintf("exit code %d", exitCode)
+	}
+	if req.Verbose {
+		debugInfo.WriteString(fmt.Sprintf("Execution completed in %dms\n", resp.Duration))
+		resp.Debug = debugInfo.String()
+	}
+	return resp
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// streamFrame is one incremental chunk of output emitted while a streaming
+// command is running.
+type streamFrame struct {
+	Stream string `json:"stream"` // "stdout" or "stderr"
+	Chunk  string `json:"chunk"`
+	Seq    int64  `json:"seq"`
+}
+
+// streamDone is the terminal frame that closes out a streaming command.
+type streamDone struct {
+	Done       bool   `json:"done"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	Killed     bool   `json:"killed,omitempty"`
+}
+
+// streamByteLimiter caps the total bytes streamCommand will emit across
+// stdout and stderr frames combined, the streaming analogue of limitedBuffer:
+// once the budget is spent, further chunks are silently dropped rather than
+// erroring, instead of unbounding the response like the rest of chunk0-4
+// guards against.
+type streamByteLimiter struct {
+	mu   sync.Mutex
+	used int64
+	max  int64
+}
+
+// allow reports whether n more bytes can still be emitted, reserving them if
+// so. max <= 0 means unlimited.
+func (l *streamByteLimiter) allow(n int) bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.used >= l.max {
+		return false
+	}
+	l.used += int64(n)
+	return true
+}
+
+// streamCommand runs req.Command and emits its output as it arrives, one
+// streamFrame per line, finishing with a streamDone frame. Unlike
+// executeCommand it never buffers the whole output before replying, so
+// callers building progress UIs can show output as it's produced. Like the
+// plain exec path, req.TimeoutMs/MaxOutputBytes/KillTree bound it instead of
+// letting a runaway streaming command run forever.
+func streamCommand(req Request) {
+	start := time.Now()
+
+	var cmd *exec.Cmd
+	switch req.Type {
+	case "powershell", "ps":
+		cmd = exec.Command("powershell", "-Command", req.Command)
+	case "wsl":
+		cmd = exec.Command("wsl", "--", "bash", "-c", req.Command)
+	default: // "cmd" or empty
+		cmd = exec.Command("cmd", "/C", req.Command)
+	}
+	prepareKillTree(cmd, req.KillTree)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		writeJSON(streamDone{Done: true, DurationMs: time.Since(start).Milliseconds(), Error: err.Error()})
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		writeJSON(streamDone{Done: true, DurationMs: time.Since(start).Milliseconds(), Error: err.Error()})
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		writeJSON(streamDone{Done: true, DurationMs: time.Since(start).Milliseconds(), Error: err.Error()})
+		return
+	}
+
+	kt := attachKillTree(cmd, req.KillTree)
+	defer kt.close()
+
+	var seq int64
+	limiter := &streamByteLimiter{max: req.MaxOutputBytes}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go pumpStream(stdout, "stdout", &seq, limiter, &wg)
+	go pumpStream(stderr, "stderr", &seq, limiter, &wg)
+	pumped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(pumped)
+	}()
+
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- cmd.Wait() }()
+
+	var waitErr error
+	var timedOut bool
+	if req.TimeoutMs <= 0 {
+		waitErr = <-waitDone
+	} else {
+		select {
+		case waitErr = <-waitDone:
+		case <-time.After(time.Duration(req.TimeoutMs) * time.Millisecond):
+			timedOut = true
+			// cmd.Process.Kill() terminates the child even when kill_tree
+			// wasn't requested (see limits.go's runWithLimits); kt.kill()
+			// additionally takes the whole descendant tree down when it was.
+			cmd.Process.Kill()
+			kt.kill()
+			waitErr = <-waitDone
+		}
+	}
+	<-pumped // drain whatever's left of stdout/stderr before reporting done
+
+	done := streamDone{
+		Done:       true,
+		DurationMs: time.Since(start).Milliseconds(),
+		TimedOut:   timedOut,
+		Killed:     timedOut,
+	}
+	if timedOut {
+		done.Error = "command timed out"
+	} else if waitErr != nil {
+		done.Error = waitErr.Error()
+	}
+	writeJSON(done)
+}
+
+// pumpStream reads r line by line, emitting a streamFrame per line tagged
+// with which pipe it came from and a monotonically increasing sequence
+// number shared across stdout and stderr, until limiter's byte budget runs
+// out. It keeps draining r after that point so the child's pipe never fills
+// up and blocks it, it just stops emitting frames.
+func pumpStream(r io.Reader, name string, seq *int64, limiter *streamByteLimiter, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if !limiter.allow(len(text)) {
+			continue
+		}
+		writeJSON(streamFrame{
+			Stream: name,
+			Chunk:  text,
+			Seq:    atomic.AddInt64(seq, 1),
+		})
+	}
+}